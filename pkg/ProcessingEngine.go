@@ -2,24 +2,113 @@ package processingEngine
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"regexp"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 )
 
+// Sentinel errors returned by RunContext for conditions that carry no extra
+// data. Use errors.Is(err, ErrBinaryNotFound) (etc) to detect them, rather
+// than parsing the error message.
+var (
+	// ErrBinaryNotFound is returned when pe.binPath does not exist or is not a regular file.
+	ErrBinaryNotFound = errors.New("processingEngine: binary not found")
+	// ErrBinaryNotExecutable is returned when pe.binPath exists but lacks executable permissions.
+	ErrBinaryNotExecutable = errors.New("processingEngine: binary is not executable")
+	// ErrEnvFileUnreadable is returned when an env file in pe.envFilePaths does not exist, is not a regular file, or cannot be read.
+	ErrEnvFileUnreadable = errors.New("processingEngine: environment file is not readable")
+	// ErrContextDone is returned when ctx is cancelled (context.Canceled) before or during command execution.
+	// A deadline being exceeded is reported as ErrTimeout instead.
+	ErrContextDone = errors.New("processingEngine: context done before or during command execution")
+)
+
+// ErrNonZeroExit is returned when the command runs to completion but exits
+// with a non-zero status, so a caller can no longer mistake a failing command
+// for success by ignoring the error.
+type ErrNonZeroExit struct {
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ErrNonZeroExit) Error() string {
+	return fmt.Sprintf("processingEngine: command exited with code %d: %s", e.ExitCode, e.Stderr)
+}
+
+// Is reports whether target is an *ErrNonZeroExit, regardless of field values,
+// so callers can do errors.Is(err, &ErrNonZeroExit{}) without knowing the exit code up front.
+func (e *ErrNonZeroExit) Is(target error) bool {
+	_, ok := target.(*ErrNonZeroExit)
+	return ok
+}
+
+// ErrSignaled is returned when the command is terminated by a signal rather than exiting normally.
+type ErrSignaled struct {
+	Signal syscall.Signal
+}
+
+func (e *ErrSignaled) Error() string {
+	return fmt.Sprintf("processingEngine: command terminated by signal %s", e.Signal)
+}
+
+func (e *ErrSignaled) Is(target error) bool {
+	_, ok := target.(*ErrSignaled)
+	return ok
+}
+
+// ErrTimeout is returned by RunContext when ctx's deadline is exceeded before the command finishes.
+type ErrTimeout struct {
+	Elapsed time.Duration
+}
+
+func (e *ErrTimeout) Error() string {
+	return fmt.Sprintf("processingEngine: command timed out after %s", e.Elapsed)
+}
+
+func (e *ErrTimeout) Is(target error) bool {
+	_, ok := target.(*ErrTimeout)
+	return ok
+}
+
 // ProcessingEngine is a struct that can run a binary with arguments and environment variables
 // and capture the stdout, stderr, and exit code
-// The environment variables are read from an envFilePath, which should contain lines of the form KEY=VALUE (i.e. matching regexp ^[^#]*=.*)
-// that will be appended to the command's environment
+// The environment variables are read from one or more env files (see ParseEnvFile for the
+// supported syntax) that will be appended to the command's environment, later files overriding earlier ones
 type ProcessingEngine struct {
 	// binPath should be path to executable file
 	binPath string
 
-	// "" or a readable file
+	// "" or a readable file; kept for GetEnvFilePath() back-compat. "" when
+	// the engine was built with NewProcessingEngineWithEnvFiles.
 	envFilePath string
-	args        []string
+	// the env file(s) actually parsed by RunContext, in override order (later
+	// files' keys win); populated by every constructor
+	envFilePaths []string
+	args         []string
+
+	// when true, binPath is resolved via the effective child PATH (see
+	// NewProcessingEngineLookPath) instead of being used as-is
+	lookPath bool
+
+	// "" means inherit the caller's working directory
+	workingDir string
+
+	// optional: when nil, the child inherits no stdin
+	stdin io.Reader
+	// optional: when set, stdout/stderr are streamed to these writers in
+	// addition to being captured in the in-memory buffers below
+	stdoutWriter io.Writer
+	stderrWriter io.Writer
+	// maxCaptureBytes caps how many bytes of stdout/stderr are retained in
+	// memory (pe.stdout / pe.stderr). 0 means unlimited. It does not limit
+	// what is forwarded to stdoutWriter/stderrWriter.
+	maxCaptureBytes int64
 
 	stdout   string
 	stderr   string
@@ -27,86 +116,196 @@ type ProcessingEngine struct {
 }
 
 func NewProcessingEngine(binPath string, envFilePath string, args []string) *ProcessingEngine {
-	return &ProcessingEngine{
+	pe := &ProcessingEngine{
 		binPath:     binPath,
 		envFilePath: envFilePath,
 		args:        args,
 	}
+	if envFilePath != "" {
+		pe.envFilePaths = []string{envFilePath}
+	}
+	return pe
+}
+
+// NewProcessingEngineWithEnvFiles is like NewProcessingEngine, but layers
+// multiple env files instead of one: each is parsed with ParseEnvFile, in
+// order, and later files override keys set by earlier ones (and both
+// override os.Environ()).
+func NewProcessingEngineWithEnvFiles(binPath string, envFiles []string, args []string) *ProcessingEngine {
+	pe := NewProcessingEngine(binPath, "", args)
+	pe.envFilePaths = envFiles
+	return pe
+}
+
+// NewProcessingEngineLookPath is like NewProcessingEngine, but when binPath
+// contains no path separator it is resolved via the PATH that will actually
+// be passed to the child (os.Environ() merged with envFilePath entries),
+// rather than the calling process's own PATH. This lets a binPath like "make"
+// resolve relative to a PATH override coming from envFilePath, and combined
+// with SetWorkingDir lets it resolve relative to a "." PATH entry pointing at
+// a caller-chosen directory.
+func NewProcessingEngineLookPath(binPath string, envFilePath string, args []string) *ProcessingEngine {
+	pe := NewProcessingEngine(binPath, envFilePath, args)
+	pe.lookPath = true
+	return pe
+}
+
+// SetStdin sets the reader that will be connected to the child process's stdin.
+func (pe *ProcessingEngine) SetStdin(r io.Reader) {
+	pe.stdin = r
+}
+
+// SetWorkingDir sets the working directory the command is run from. "" (the
+// default) means inherit the calling process's working directory. It also
+// anchors relative PATH entries when binPath is resolved via
+// NewProcessingEngineLookPath.
+func (pe *ProcessingEngine) SetWorkingDir(dir string) {
+	pe.workingDir = dir
+}
+
+// SetStdoutWriter sets a writer that stdout is streamed to as it is produced,
+// in addition to being captured (up to the SetMaxCaptureBytes cap) for GetStdout().
+func (pe *ProcessingEngine) SetStdoutWriter(w io.Writer) {
+	pe.stdoutWriter = w
+}
+
+// SetStderrWriter sets a writer that stderr is streamed to as it is produced,
+// in addition to being captured (up to the SetMaxCaptureBytes cap) for GetStderr().
+func (pe *ProcessingEngine) SetStderrWriter(w io.Writer) {
+	pe.stderrWriter = w
+}
+
+// SetMaxCaptureBytes caps how many bytes of stdout/stderr are retained in the
+// in-memory buffers returned by GetStdout()/GetStderr(), protecting against
+// runaway processes that fill memory. It does not truncate what is forwarded
+// to any writer set via SetStdoutWriter/SetStderrWriter. n <= 0 means unlimited.
+func (pe *ProcessingEngine) SetMaxCaptureBytes(n int64) {
+	pe.maxCaptureBytes = n
 }
 
 // Run the binpath with args and envFilePath, and set stdout, stderr, and exitCode
 //
+// Run delegates to RunContext(context.Background()); it never times out or
+// gets cancelled on its own.
+func (pe *ProcessingEngine) Run() (exitCode int, er error) {
+	return pe.RunContext(context.Background())
+}
+
+// RunContext behaves like Run, but the command is executed with
+// exec.CommandContext(ctx, ...) so callers can cancel it or apply a deadline.
+//
 // Verifications
 // - Verify that pe.binPath is a file with executable permissions
-// - Verify that pe.envFilePath is a readable file
+// - Verify that every env file is a readable file parseable by ParseEnvFile
 //
-// # Execute pe.binPath with pe.args and pe.envFilePath, and set pe.stdout, pe.stderr, and pe.exitCode
+// # Execute pe.binPath with pe.args and env files, and set pe.stdout, pe.stderr, and pe.exitCode
 //
-// The envFilePath lines of the form KEY=VALUE (i.e. matching regexp ^[^#]*=.*) will be appended to the command's environment
-func (pe *ProcessingEngine) Run() (exitCode int, er error) {
-	// Verifications
-	// - Verify that pe.binPath is a file with executable permissions
-	// - Verify that pe.envFilePath is a readable file
-	{
-		// Verify that pe.binPath is a file with executable permissions
-		if stat, err := os.Stat(pe.binPath); err != nil {
-			return 0, fmt.Errorf("error verifying binary at %s: %s", pe.binPath, err)
+// RunContext returns structured errors instead of fmt.Errorf strings, so
+// callers can tell causes apart with errors.Is/errors.As instead of parsing
+// English prose: ErrBinaryNotFound, ErrBinaryNotExecutable, ErrEnvFileUnreadable,
+// ErrEnvFileMalformed, ErrContextDone, ErrTimeout, ErrSignaled, and ErrNonZeroExit.
+// A failure to start the command that is none of these (not an *exec.ExitError)
+// is still returned as a plain, unstructured error. In particular, a
+// command that runs but exits non-zero now returns ErrNonZeroExit instead of a
+// nil error - callers relying on the old "(exitCode, nil)" behaviour must switch
+// to inspecting the returned error (or GetExitCode()).
+func (pe *ProcessingEngine) RunContext(ctx context.Context) (exitCode int, er error) {
+	// reset from any previous call, so a reused engine that fails early
+	// (env file, binary resolution, etc) can't report a prior call's stale exit code/output
+	pe.exitCode = 0
+	pe.stdout = ""
+	pe.stderr = ""
+
+	// Build the child environment: os.Environ() appended with each env file's
+	// entries, parsed with ParseEnvFile, in order (later files' keys win,
+	// matching os/exec's last-value-wins semantics for duplicate keys)
+	env := os.Environ()
+	for _, envFilePath := range pe.envFilePaths {
+		if stat, err := os.Stat(envFilePath); err != nil {
+			return 0, fmt.Errorf("%w: %s: %s", ErrEnvFileUnreadable, envFilePath, err)
 		} else if !stat.Mode().IsRegular() {
-			return 0, fmt.Errorf("binary at %s is not a regular file", pe.binPath)
-		} else if stat.Mode()&0111 == 0 {
-			return 0, fmt.Errorf("binary at %s is not executable", pe.binPath)
+			return 0, fmt.Errorf("%w: %s is not a regular file", ErrEnvFileUnreadable, envFilePath)
+		} else if stat.Mode()&0444 == 0 {
+			return 0, fmt.Errorf("%w: %s", ErrEnvFileUnreadable, envFilePath)
 		}
 
-		// Verify that pe.envFilePath is "" or a readable file
-		if pe.envFilePath != "" {
-			if stat, err := os.Stat(pe.envFilePath); err != nil {
-				return 0, fmt.Errorf("error verifying environment file at %s: %s", pe.envFilePath, err)
-			} else if !stat.Mode().IsRegular() {
-				return 0, fmt.Errorf("environment file at %s is not a regular file", pe.envFilePath)
-			} else if stat.Mode()&0444 == 0 {
-				return 0, fmt.Errorf("environment file at %s is not readable", pe.envFilePath)
-			}
+		f, err := os.Open(envFilePath)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s: %s", ErrEnvFileUnreadable, envFilePath, err)
+		}
+		entries, err := ParseEnvFile(f)
+		f.Close()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", envFilePath, err)
 		}
+		env = append(env, entries...)
+	}
+
+	// Resolve pe.binPath, using the PATH from the environment computed above
+	// (not the calling process's own PATH) when NewProcessingEngineLookPath was used
+	binPath, err := pe.resolveBinPath(env)
+	if err != nil {
+		return 0, err
+	}
+
+	// Verify that binPath is a file with executable permissions
+	if stat, err := os.Stat(binPath); err != nil {
+		return 0, fmt.Errorf("%w: %s: %s", ErrBinaryNotFound, binPath, err)
+	} else if !stat.Mode().IsRegular() {
+		return 0, fmt.Errorf("%w: %s is not a regular file", ErrBinaryNotFound, binPath)
+	} else if stat.Mode()&0111 == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrBinaryNotExecutable, binPath)
 	}
 
-	// Execute pe.binPath with pe.args and pe.envFilePath, and set pe.stdout, pe.stderr, and pe.exitCode.
-	// The envFilePath lines of the form KEY=VALUE (i.e. matching regexp ^[^#]*=.*) will be appended to the command's environment
-	// - set command.Env, containing os.Environ() appended with pe.envFilePath lines that match regexp ^[^#]*=.*
+	// Execute binPath with pe.args and the computed env, and set pe.stdout, pe.stderr, and pe.exitCode
 	{
-		command := exec.Command(pe.binPath, pe.args...)
-		// The envFilePath lines of the form KEY=VALUE (i.e. matching regexp ^[^#]*=.*) will be appended to the command's environment
-		{
-			command.Env = os.Environ()
-
-			if pe.envFilePath != "" {
-				dat, err := os.ReadFile(pe.envFilePath)
-				if err != nil {
-					return 0, fmt.Errorf("error opening environment file at %s: %s", pe.envFilePath, err)
-				}
-
-				for _, line := range strings.Split(string(dat), "\n") {
-					// if line of envFilePath matches regexp ^[^#]*=.*
-					if !regexp.MustCompile(`^[^#]*=.*`).MatchString(line) {
-						continue
-					}
-					// - append matching line to command.Env
-					command.Env = append(command.Env, line)
-				}
-			}
+		command := exec.CommandContext(ctx, binPath, pe.args...)
+		command.Env = env
+		command.Dir = pe.workingDir
+
+		if pe.stdin != nil {
+			command.Stdin = pe.stdin
 		}
+
 		var stdoutb, stderrb bytes.Buffer
-		command.Stdout = &stdoutb
-		command.Stderr = &stderrb
+		cappedStdout := &cappedBuffer{buf: &stdoutb, max: pe.maxCaptureBytes}
+		cappedStderr := &cappedBuffer{buf: &stderrb, max: pe.maxCaptureBytes}
+
+		if pe.stdoutWriter != nil {
+			command.Stdout = io.MultiWriter(cappedStdout, pe.stdoutWriter)
+		} else {
+			command.Stdout = cappedStdout
+		}
+		if pe.stderrWriter != nil {
+			command.Stderr = io.MultiWriter(cappedStderr, pe.stderrWriter)
+		} else {
+			command.Stderr = cappedStderr
+		}
+
+		start := time.Now()
 		err := command.Run()
+		pe.stdout = stdoutb.String()
+		pe.stderr = stderrb.String()
+
 		if err != nil {
-			if exitError, ok := err.(*exec.ExitError); ok {
-				pe.exitCode = exitError.ExitCode()
-			} else {
+			if ctx.Err() == context.DeadlineExceeded {
+				return 0, &ErrTimeout{Elapsed: time.Since(start)}
+			} else if ctx.Err() != nil {
+				return 0, fmt.Errorf("%w: %s", ErrContextDone, ctx.Err())
+			}
+
+			exitError, ok := err.(*exec.ExitError)
+			if !ok {
 				return 0, fmt.Errorf("error when running the command: %s", err)
 			}
+
+			if ws, ok := exitError.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+				return 0, &ErrSignaled{Signal: ws.Signal()}
+			}
+
+			pe.exitCode = exitError.ExitCode()
+			return pe.exitCode, &ErrNonZeroExit{ExitCode: pe.exitCode, Stderr: pe.stderr}
 		}
-		pe.stdout = stdoutb.String()
-		pe.stderr = stderrb.String()
 	}
 
 	exitCode = pe.exitCode
@@ -114,6 +313,68 @@ func (pe *ProcessingEngine) Run() (exitCode int, er error) {
 	return
 }
 
+// resolveBinPath returns the path that should actually be executed. If
+// pe.lookPath is false, or pe.binPath already contains a path separator (and
+// so is meant to be used as-is, relative or absolute), pe.binPath is returned
+// unchanged. Otherwise pe.binPath is resolved by walking the PATH entries of
+// env - the environment that will actually be passed to the child, not the
+// calling process's own os.Getenv("PATH") - anchoring relative PATH entries
+// at pe.workingDir.
+func (pe *ProcessingEngine) resolveBinPath(env []string) (string, error) {
+	if !pe.lookPath || strings.ContainsRune(pe.binPath, os.PathSeparator) {
+		return pe.binPath, nil
+	}
+
+	pathEnv := ""
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "PATH=") {
+			pathEnv = strings.TrimPrefix(kv, "PATH=")
+		}
+	}
+
+	for _, dir := range filepath.SplitList(pathEnv) {
+		if dir == "" {
+			dir = "."
+		}
+		if !filepath.IsAbs(dir) && pe.workingDir != "" {
+			dir = filepath.Join(pe.workingDir, dir)
+		}
+
+		candidate := filepath.Join(dir, pe.binPath)
+		if stat, err := os.Stat(candidate); err == nil && stat.Mode().IsRegular() && stat.Mode()&0111 != 0 {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s not found in PATH", ErrBinaryNotFound, pe.binPath)
+}
+
+// cappedBuffer writes into buf, but retains at most max bytes (max <= 0 means
+// unlimited). It always reports having written the full input so that it can
+// be combined with other writers via io.MultiWriter without truncating them.
+type cappedBuffer struct {
+	buf     *bytes.Buffer
+	max     int64
+	written int64
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if c.max <= 0 {
+		c.buf.Write(p)
+		return len(p), nil
+	}
+
+	if remaining := c.max - c.written; remaining > 0 {
+		n := int64(len(p))
+		if remaining < n {
+			n = remaining
+		}
+		c.buf.Write(p[:n])
+		c.written += n
+	}
+	return len(p), nil
+}
+
 func (pe *ProcessingEngine) GetStdout() string {
 	return pe.stdout
 }
@@ -129,6 +390,9 @@ func (pe *ProcessingEngine) GetBinPath() string {
 func (pe *ProcessingEngine) GetEnvFilePath() string {
 	return pe.envFilePath
 }
+func (pe *ProcessingEngine) GetEnvFilePaths() []string {
+	return pe.envFilePaths
+}
 func (pe *ProcessingEngine) GetArgs() []string {
 	return pe.args
 }
@@ -1,9 +1,15 @@
 package processingEngine
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 )
 
 // TestProcessingEngineRunValid verifies that Run() returns the expected exit code, stdout, and stderr
@@ -41,8 +47,14 @@ KEY2=VALUE2
 
 	// Run the ProcessingEngine
 	exitCode, err := pe.Run()
-	if err != nil {
-		t.Fatalf("error running ProcessingEngine: %s", err)
+
+	// A non-zero exit code is reported as ErrNonZeroExit rather than a nil error
+	var nonZeroExit *ErrNonZeroExit
+	if !errors.As(err, &nonZeroExit) {
+		t.Fatalf("error: got %v, want errors.As(err, &ErrNonZeroExit{})", err)
+	}
+	if nonZeroExit.ExitCode != 42 {
+		t.Errorf("ErrNonZeroExit.ExitCode: got %d, want %d", nonZeroExit.ExitCode, 42)
 	}
 
 	// Verify the exit code
@@ -63,3 +75,409 @@ KEY2=VALUE2
 	}
 
 }
+
+// TestProcessingEngineRunContextTimeout verifies that RunContext returns
+// ErrTimeout when the context's deadline is exceeded before the command finishes
+func TestProcessingEngineRunContextTimeout(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-context-timeout")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binPath := tempDir + "/bin"
+	if err := ioutil.WriteFile(binPath, []byte(`#!/bin/bash
+exec sleep 5
+`), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	pe := NewProcessingEngine(binPath, "", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = pe.RunContext(ctx)
+	var timeout *ErrTimeout
+	if !errors.As(err, &timeout) {
+		t.Fatalf("error: got %v, want errors.As(err, &ErrTimeout{})", err)
+	}
+}
+
+// TestProcessingEngineRunContextCancelled verifies that RunContext returns
+// ErrContextDone when the context is explicitly cancelled (no deadline)
+func TestProcessingEngineRunContextCancelled(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-context-cancelled")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binPath := tempDir + "/bin"
+	if err := ioutil.WriteFile(binPath, []byte(`#!/bin/bash
+exec sleep 5
+`), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	pe := NewProcessingEngine(binPath, "", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err = pe.RunContext(ctx)
+	if !errors.Is(err, ErrContextDone) {
+		t.Fatalf("error: got %v, want errors.Is(err, ErrContextDone)", err)
+	}
+}
+
+// TestProcessingEngineSetStdoutWriter verifies that stdout is streamed to the
+// writer set via SetStdoutWriter while still being captured for GetStdout()
+func TestProcessingEngineSetStdoutWriter(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-stdout-writer")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binPath := tempDir + "/bin"
+	if err := ioutil.WriteFile(binPath, []byte(`#!/bin/bash
+echo hello
+`), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	pe := NewProcessingEngine(binPath, "", nil)
+	var streamed bytes.Buffer
+	pe.SetStdoutWriter(&streamed)
+
+	if _, err := pe.Run(); err != nil {
+		t.Fatalf("error running ProcessingEngine: %s", err)
+	}
+
+	expected := "hello\n"
+	if streamed.String() != expected {
+		t.Errorf("streamed stdout: got %q, want %q", streamed.String(), expected)
+	}
+	if pe.GetStdout() != expected {
+		t.Errorf("captured stdout: got %q, want %q", pe.GetStdout(), expected)
+	}
+}
+
+// TestProcessingEngineSetMaxCaptureBytes verifies that GetStdout() is capped at
+// the SetMaxCaptureBytes limit while a writer set via SetStdoutWriter still receives everything
+func TestProcessingEngineSetMaxCaptureBytes(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-max-capture")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binPath := tempDir + "/bin"
+	if err := ioutil.WriteFile(binPath, []byte(`#!/bin/bash
+printf '0123456789'
+`), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	pe := NewProcessingEngine(binPath, "", nil)
+	pe.SetMaxCaptureBytes(4)
+	var streamed bytes.Buffer
+	pe.SetStdoutWriter(&streamed)
+
+	if _, err := pe.Run(); err != nil {
+		t.Fatalf("error running ProcessingEngine: %s", err)
+	}
+
+	if pe.GetStdout() != "0123" {
+		t.Errorf("capped stdout: got %q, want %q", pe.GetStdout(), "0123")
+	}
+	if streamed.String() != "0123456789" {
+		t.Errorf("streamed stdout: got %q, want %q", streamed.String(), "0123456789")
+	}
+}
+
+// TestProcessingEngineRunBinaryNotFound verifies that Run() returns
+// ErrBinaryNotFound when binPath does not exist
+func TestProcessingEngineRunBinaryNotFound(t *testing.T) {
+	pe := NewProcessingEngine("/does/not/exist", "", nil)
+
+	if _, err := pe.Run(); !errors.Is(err, ErrBinaryNotFound) {
+		t.Fatalf("error: got %v, want errors.Is(err, ErrBinaryNotFound)", err)
+	}
+}
+
+// TestProcessingEngineRunBinaryNotExecutable verifies that Run() returns
+// ErrBinaryNotExecutable when binPath exists but lacks executable permissions
+func TestProcessingEngineRunBinaryNotExecutable(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-not-executable")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binPath := tempDir + "/bin"
+	if err := ioutil.WriteFile(binPath, []byte("#!/bin/bash\n"), 0644); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	pe := NewProcessingEngine(binPath, "", nil)
+	if _, err := pe.Run(); !errors.Is(err, ErrBinaryNotExecutable) {
+		t.Fatalf("error: got %v, want errors.Is(err, ErrBinaryNotExecutable)", err)
+	}
+}
+
+// TestProcessingEngineRunEnvFileUnreadable verifies that Run() returns
+// ErrEnvFileUnreadable when envFilePath does not exist
+func TestProcessingEngineRunEnvFileUnreadable(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-env-unreadable")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binPath := tempDir + "/bin"
+	if err := ioutil.WriteFile(binPath, []byte("#!/bin/bash\nexit 0\n"), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	pe := NewProcessingEngine(binPath, tempDir+"/does-not-exist", nil)
+	if _, err := pe.Run(); !errors.Is(err, ErrEnvFileUnreadable) {
+		t.Fatalf("error: got %v, want errors.Is(err, ErrEnvFileUnreadable)", err)
+	}
+}
+
+// TestProcessingEngineRunSignaled verifies that Run() returns ErrSignaled
+// when the command is terminated by a signal
+func TestProcessingEngineRunSignaled(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-signaled")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binPath := tempDir + "/bin"
+	if err := ioutil.WriteFile(binPath, []byte(`#!/bin/bash
+kill -TERM $$
+`), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	pe := NewProcessingEngine(binPath, "", nil)
+
+	_, err = pe.Run()
+	var signaled *ErrSignaled
+	if !errors.As(err, &signaled) {
+		t.Fatalf("error: got %v, want errors.As(err, &ErrSignaled{})", err)
+	}
+	if signaled.Signal != syscall.SIGTERM {
+		t.Errorf("ErrSignaled.Signal: got %s, want %s", signaled.Signal, syscall.SIGTERM)
+	}
+}
+
+// TestProcessingEngineLookPath verifies that NewProcessingEngineLookPath resolves
+// binPath against the PATH entry coming from envFilePath, not the test process's own PATH
+func TestProcessingEngineLookPath(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-lookpath")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binDir := tempDir + "/bindir"
+	if err := os.Mkdir(binDir, 0755); err != nil {
+		t.Fatalf("error creating bin directory: %s", err)
+	}
+	binPath := binDir + "/mytool"
+	if err := ioutil.WriteFile(binPath, []byte("#!/bin/bash\necho found\n"), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	envFilePath := tempDir + "/env"
+	if err := ioutil.WriteFile(envFilePath, []byte("PATH="+binDir+"\n"), 0644); err != nil {
+		t.Fatalf("error creating temporary environment file: %s", err)
+	}
+
+	pe := NewProcessingEngineLookPath("mytool", envFilePath, nil)
+
+	if _, err := pe.Run(); err != nil {
+		t.Fatalf("error running ProcessingEngine: %s", err)
+	}
+
+	expected := "found\n"
+	if pe.GetStdout() != expected {
+		t.Errorf("stdout: got %q, want %q", pe.GetStdout(), expected)
+	}
+}
+
+// TestProcessingEngineSetWorkingDir verifies that the command is run from the
+// directory set via SetWorkingDir
+func TestProcessingEngineSetWorkingDir(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-working-dir")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binPath := tempDir + "/bin"
+	if err := ioutil.WriteFile(binPath, []byte("#!/bin/bash\npwd\n"), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	workDir := tempDir + "/workdir"
+	if err := os.Mkdir(workDir, 0755); err != nil {
+		t.Fatalf("error creating working directory: %s", err)
+	}
+	// resolve symlinks (e.g. /tmp -> /private/tmp on macOS) so the comparison below is exact
+	resolvedWorkDir, err := filepath.EvalSymlinks(workDir)
+	if err != nil {
+		t.Fatalf("error resolving working directory: %s", err)
+	}
+
+	pe := NewProcessingEngine(binPath, "", nil)
+	pe.SetWorkingDir(workDir)
+
+	if _, err := pe.Run(); err != nil {
+		t.Fatalf("error running ProcessingEngine: %s", err)
+	}
+
+	expected := resolvedWorkDir + "\n"
+	if pe.GetStdout() != expected {
+		t.Errorf("stdout: got %q, want %q", pe.GetStdout(), expected)
+	}
+}
+
+// TestProcessingEngineWithEnvFiles verifies that NewProcessingEngineWithEnvFiles
+// layers multiple env files, with later files overriding earlier ones
+func TestProcessingEngineWithEnvFiles(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-env-files")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binPath := tempDir + "/bin"
+	if err := ioutil.WriteFile(binPath, []byte("#!/bin/bash\necho $KEY1 $KEY2\n"), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	baseEnvFilePath := tempDir + "/base.env"
+	if err := ioutil.WriteFile(baseEnvFilePath, []byte("KEY1=base1\nKEY2=base2\n"), 0644); err != nil {
+		t.Fatalf("error creating temporary environment file: %s", err)
+	}
+	overrideEnvFilePath := tempDir + "/override.env"
+	if err := ioutil.WriteFile(overrideEnvFilePath, []byte("KEY2=override2\n"), 0644); err != nil {
+		t.Fatalf("error creating temporary environment file: %s", err)
+	}
+
+	pe := NewProcessingEngineWithEnvFiles(binPath, []string{baseEnvFilePath, overrideEnvFilePath}, nil)
+
+	if _, err := pe.Run(); err != nil {
+		t.Fatalf("error running ProcessingEngine: %s", err)
+	}
+
+	expected := "base1 override2\n"
+	if pe.GetStdout() != expected {
+		t.Errorf("stdout: got %q, want %q", pe.GetStdout(), expected)
+	}
+}
+
+// TestProcessingEngineRunResetsExitCodeOnReuse verifies that reusing the same
+// ProcessingEngine for a second Run() that exits 0 doesn't report the stale
+// exit code from a prior non-zero run
+func TestProcessingEngineRunResetsExitCodeOnReuse(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-reset-exit-code")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	markerPath := tempDir + "/marker"
+	binPath := tempDir + "/bin"
+	if err := ioutil.WriteFile(binPath, []byte(`#!/bin/bash
+if [ -e "`+markerPath+`" ]; then
+  exit 0
+fi
+exit 42
+`), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	pe := NewProcessingEngine(binPath, "", nil)
+
+	exitCode, err := pe.Run()
+	var nonZeroExit *ErrNonZeroExit
+	if !errors.As(err, &nonZeroExit) {
+		t.Fatalf("first run: error = %v, want errors.As(err, &ErrNonZeroExit{})", err)
+	}
+	if exitCode != 42 {
+		t.Fatalf("first run: exitCode = %d, want 42", exitCode)
+	}
+
+	if err := ioutil.WriteFile(markerPath, nil, 0644); err != nil {
+		t.Fatalf("error creating marker file: %s", err)
+	}
+
+	exitCode, err = pe.Run()
+	if err != nil {
+		t.Fatalf("second run: unexpected error: %s", err)
+	}
+	if exitCode != 0 {
+		t.Errorf("second run: exitCode = %d, want 0", exitCode)
+	}
+	if pe.GetExitCode() != 0 {
+		t.Errorf("second run: GetExitCode() = %d, want 0", pe.GetExitCode())
+	}
+}
+
+// TestProcessingEngineRunResetsStateOnEarlyFailure verifies that a second
+// Run() that fails during verification (here: the binary disappears) doesn't
+// leave GetExitCode()/GetStdout()/GetStderr() reporting the first run's stale values
+func TestProcessingEngineRunResetsStateOnEarlyFailure(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "run-reset-early-failure")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binPath := tempDir + "/bin"
+	if err := ioutil.WriteFile(binPath, []byte(`#!/bin/bash
+echo out
+echo err >&2
+exit 42
+`), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+
+	pe := NewProcessingEngine(binPath, "", nil)
+
+	exitCode, err := pe.Run()
+	var nonZeroExit *ErrNonZeroExit
+	if !errors.As(err, &nonZeroExit) {
+		t.Fatalf("first run: error = %v, want errors.As(err, &ErrNonZeroExit{})", err)
+	}
+	if exitCode != 42 || pe.GetStdout() != "out\n" || pe.GetStderr() != "err\n" {
+		t.Fatalf("first run: got exitCode=%d stdout=%q stderr=%q, want 42/\"out\\n\"/\"err\\n\"", exitCode, pe.GetStdout(), pe.GetStderr())
+	}
+
+	if err := os.Remove(binPath); err != nil {
+		t.Fatalf("error removing binary: %s", err)
+	}
+
+	exitCode, err = pe.Run()
+	if !errors.Is(err, ErrBinaryNotFound) {
+		t.Fatalf("second run: error = %v, want errors.Is(err, ErrBinaryNotFound)", err)
+	}
+	if exitCode != 0 {
+		t.Errorf("second run: exitCode = %d, want 0", exitCode)
+	}
+	if pe.GetExitCode() != 0 {
+		t.Errorf("second run: GetExitCode() = %d, want 0", pe.GetExitCode())
+	}
+	if pe.GetStdout() != "" {
+		t.Errorf("second run: GetStdout() = %q, want \"\"", pe.GetStdout())
+	}
+	if pe.GetStderr() != "" {
+		t.Errorf("second run: GetStderr() = %q, want \"\"", pe.GetStderr())
+	}
+}
@@ -0,0 +1,122 @@
+package processingEngine
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of running a single ProcessingEngine as part of a Batch.
+type Result struct {
+	PE       *ProcessingEngine
+	ExitCode int
+	Err      error
+	Duration time.Duration
+}
+
+// Batch runs many ProcessingEngine invocations concurrently, bounded by a
+// worker pool, and collects their Results.
+type Batch struct {
+	concurrency int
+	// 0 means unlimited: how many engines with a stdout/stderr writer set (see
+	// SetStdoutWriter/SetStderrWriter) may run at once, independently of
+	// concurrency. Useful when some binaries emit gigabytes of output and
+	// others are cheap, so the output-heavy ones don't dominate every worker slot.
+	runOutputLimit int
+
+	engines []*ProcessingEngine
+}
+
+// NewBatch creates a Batch that runs at most concurrency engines at once.
+// concurrency < 1 is treated as 1.
+func NewBatch(concurrency int) *Batch {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Batch{concurrency: concurrency}
+}
+
+// Add queues pe to be run by RunContext.
+func (b *Batch) Add(pe *ProcessingEngine) {
+	b.engines = append(b.engines, pe)
+}
+
+// SetRunOutputLimit caps how many engines with a stdout/stderr writer set run
+// simultaneously, independently of the overall concurrency. n <= 0 means unlimited.
+func (b *Batch) SetRunOutputLimit(n int) {
+	b.runOutputLimit = n
+}
+
+// Shard returns a new Batch containing only the engines of b whose
+// binPath+args hash (via hash/fnv) falls into shard index of total, so a CI
+// system can split a large Batch across total machines by giving each one a
+// distinct index in [0, total). index/total are not validated: total <= 1
+// returns a Batch with every engine, in a single shard.
+func (b *Batch) Shard(index, total int) *Batch {
+	shard := &Batch{concurrency: b.concurrency, runOutputLimit: b.runOutputLimit}
+
+	if total <= 1 {
+		shard.engines = append(shard.engines, b.engines...)
+		return shard
+	}
+
+	for _, pe := range b.engines {
+		h := fnv.New32a()
+		h.Write([]byte(pe.binPath))
+		for _, arg := range pe.args {
+			h.Write([]byte{0})
+			h.Write([]byte(arg))
+		}
+		if int(h.Sum32()%uint32(total)) == index {
+			shard.engines = append(shard.engines, pe)
+		}
+	}
+	return shard
+}
+
+// Run calls RunContext(context.Background()); it never times out or gets cancelled on its own.
+func (b *Batch) Run() []Result {
+	return b.RunContext(context.Background())
+}
+
+// RunContext runs every queued engine via ProcessingEngine.RunContext(ctx),
+// bounded by the Batch's concurrency (and, for engines with an output writer
+// set, by SetRunOutputLimit), and returns one Result per engine in the order
+// engines were added. Cancelling ctx propagates to every in-flight child
+// process, since ProcessingEngine.RunContext itself runs them with
+// exec.CommandContext.
+func (b *Batch) RunContext(ctx context.Context) []Result {
+	results := make([]Result, len(b.engines))
+
+	sem := make(chan struct{}, b.concurrency)
+	var outputSem chan struct{}
+	if b.runOutputLimit > 0 {
+		outputSem = make(chan struct{}, b.runOutputLimit)
+	}
+
+	var wg sync.WaitGroup
+	for i, pe := range b.engines {
+		wg.Add(1)
+		go func(i int, pe *ProcessingEngine) {
+			defer wg.Done()
+
+			// take the output slot before the worker slot, so an engine
+			// waiting on the run output limit doesn't hold a worker slot meanwhile
+			if outputSem != nil && (pe.stdoutWriter != nil || pe.stderrWriter != nil) {
+				outputSem <- struct{}{}
+				defer func() { <-outputSem }()
+			}
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			exitCode, err := pe.RunContext(ctx)
+			results[i] = Result{PE: pe, ExitCode: exitCode, Err: err, Duration: time.Since(start)}
+		}(i, pe)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,125 @@
+package processingEngine
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func writeTestBin(t *testing.T, dir string, name string, script string) string {
+	t.Helper()
+	binPath := dir + "/" + name
+	if err := ioutil.WriteFile(binPath, []byte("#!/bin/bash\n"+script), 0755); err != nil {
+		t.Fatalf("error creating temporary binary: %s", err)
+	}
+	return binPath
+}
+
+// TestBatchRunContext verifies that RunContext runs every queued engine and
+// reports one Result per engine, in Add order
+func TestBatchRunContext(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "batch-run")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	okBin := writeTestBin(t, tempDir, "ok", "exit 0\n")
+	failBin := writeTestBin(t, tempDir, "fail", "exit 7\n")
+
+	batch := NewBatch(2)
+	batch.Add(NewProcessingEngine(okBin, "", nil))
+	batch.Add(NewProcessingEngine(failBin, "", nil))
+
+	results := batch.Run()
+	if len(results) != 2 {
+		t.Fatalf("len(results) = %d, want 2", len(results))
+	}
+	if results[0].Err != nil {
+		t.Errorf("results[0].Err = %v, want nil", results[0].Err)
+	}
+	if results[1].ExitCode != 7 {
+		t.Errorf("results[1].ExitCode = %d, want 7", results[1].ExitCode)
+	}
+	var nonZeroExit *ErrNonZeroExit
+	if !errors.As(results[1].Err, &nonZeroExit) {
+		t.Errorf("results[1].Err = %v, want *ErrNonZeroExit", results[1].Err)
+	}
+}
+
+// TestBatchRunContextCancellation verifies that cancelling ctx stops in-flight engines
+func TestBatchRunContextCancellation(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "batch-cancel")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// "exec" replaces the bash process with sleep itself, so killing the
+	// command's process also kills the sleep instead of leaving it as an
+	// orphaned child holding the stdout/stderr pipes open
+	sleepBin := writeTestBin(t, tempDir, "sleep", "exec sleep 5\n")
+
+	batch := NewBatch(2)
+	batch.Add(NewProcessingEngine(sleepBin, "", nil))
+	batch.Add(NewProcessingEngine(sleepBin, "", nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	results := batch.RunContext(ctx)
+	elapsed := time.Since(start)
+
+	if elapsed > 3*time.Second {
+		t.Errorf("RunContext took %s, want well under the 5s sleep", elapsed)
+	}
+	for i, r := range results {
+		if r.Err == nil {
+			t.Errorf("results[%d].Err = nil, want a context-done error", i)
+		}
+	}
+}
+
+// TestBatchShard verifies that Shard partitions engines deterministically and
+// without overlap across shards
+func TestBatchShard(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "batch-shard")
+	if err != nil {
+		t.Fatalf("error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	binPath := writeTestBin(t, tempDir, "bin", "exit 0\n")
+
+	const total = 3
+	batch := NewBatch(1)
+	for i := 0; i < 10; i++ {
+		batch.Add(NewProcessingEngine(binPath, "", []string{string(rune('a' + i))}))
+	}
+
+	seen := map[string]int{}
+	for shardIndex := 0; shardIndex < total; shardIndex++ {
+		shard := batch.Shard(shardIndex, total)
+		for _, pe := range shard.engines {
+			key := pe.GetArgs()[0]
+			seen[key]++
+
+			again := batch.Shard(shardIndex, total)
+			if len(again.engines) != len(shard.engines) {
+				t.Fatalf("Shard(%d, %d) is not stable across calls", shardIndex, total)
+			}
+		}
+	}
+	if len(seen) != 10 {
+		t.Errorf("sharded engines cover %d distinct args, want 10", len(seen))
+	}
+	for key, count := range seen {
+		if count != 1 {
+			t.Errorf("arg %q appears in %d shards, want exactly 1", key, count)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package processingEngine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ErrEnvFileMalformed is returned by ParseEnvFile when a line cannot be
+// parsed as KEY=VALUE, or as a quoted value with a properly closed quote.
+var ErrEnvFileMalformed = errors.New("processingEngine: environment file is malformed")
+
+var envFileKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseEnvFile parses a dotenv-style environment file and returns its entries
+// as "KEY=VALUE" strings, in file order, ready to be appended to exec.Cmd.Env.
+//
+// Supported syntax:
+//   - blank lines and lines starting with '#' (after leading whitespace) are skipped
+//   - an optional leading "export " before the key is accepted and ignored
+//   - single-quoted values ('...') are taken literally, with no escapes or expansion
+//   - double-quoted values ("...") support the escapes \n, \t, \", \\, \$, and
+//     ${VAR}/$VAR expansion, looked up against keys already parsed earlier in
+//     this file (undefined variables expand to "")
+//   - unquoted values run to the end of the line or to a '#' that starts an
+//     inline comment (a '#' only starts a comment when preceded by whitespace
+//     or at the start of the value); they are taken literally, with no expansion
+//   - a trailing unescaped '\' continues the line onto the next one
+//
+// Any line that isn't blank, a comment, or a well-formed KEY=VALUE (including
+// an unterminated quote) is rejected with a line-numbered error wrapping
+// ErrEnvFileMalformed, rather than being silently skipped.
+func ParseEnvFile(r io.Reader) ([]string, error) {
+	var result []string
+	values := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNo := 0
+	var continued string
+	for scanner.Scan() {
+		lineNo++
+		line := continued + scanner.Text()
+		continued = ""
+
+		if strings.HasSuffix(line, `\`) && !strings.HasSuffix(line, `\\`) {
+			continued = strings.TrimSuffix(line, `\`)
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		trimmed = strings.TrimPrefix(trimmed, "export ")
+
+		key, rawValue, err := splitEnvFileLine(trimmed, lineNo)
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseEnvFileValue(rawValue, values, lineNo)
+		if err != nil {
+			return nil, err
+		}
+
+		values[key] = value
+		result = append(result, key+"="+value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrEnvFileUnreadable, err)
+	}
+	if continued != "" {
+		return nil, fmt.Errorf("%w: line %d: unterminated line continuation", ErrEnvFileMalformed, lineNo)
+	}
+
+	return result, nil
+}
+
+func splitEnvFileLine(line string, lineNo int) (key string, rawValue string, err error) {
+	idx := strings.IndexByte(line, '=')
+	if idx <= 0 {
+		return "", "", fmt.Errorf("%w: line %d: expected KEY=VALUE", ErrEnvFileMalformed, lineNo)
+	}
+
+	key = strings.TrimSpace(line[:idx])
+	if !envFileKeyRegexp.MatchString(key) {
+		return "", "", fmt.Errorf("%w: line %d: invalid key %q", ErrEnvFileMalformed, lineNo, key)
+	}
+	return key, line[idx+1:], nil
+}
+
+func parseEnvFileValue(raw string, values map[string]string, lineNo int) (string, error) {
+	raw = strings.TrimLeft(raw, " \t")
+	if raw == "" {
+		return "", nil
+	}
+
+	switch raw[0] {
+	case '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("%w: line %d: unterminated single-quoted value", ErrEnvFileMalformed, lineNo)
+		}
+		value := raw[1 : 1+end]
+		if err := rejectTrailingGarbage(raw[1+end+1:], lineNo); err != nil {
+			return "", err
+		}
+		return value, nil
+
+	case '"':
+		value, consumed, err := parseDoubleQuotedValue(raw[1:], values, lineNo)
+		if err != nil {
+			return "", err
+		}
+		if err := rejectTrailingGarbage(raw[1+consumed:], lineNo); err != nil {
+			return "", err
+		}
+		return value, nil
+
+	default:
+		return parseUnquotedValue(raw), nil
+	}
+}
+
+func rejectTrailingGarbage(rest string, lineNo int) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("%w: line %d: unexpected characters after closing quote", ErrEnvFileMalformed, lineNo)
+	}
+	return nil
+}
+
+// parseDoubleQuotedValue parses s (the content following the opening '"') up
+// to and including its closing '"', applying escapes and ${VAR}/$VAR
+// expansion. It returns the decoded value and the number of bytes of s
+// consumed, including the closing quote.
+func parseDoubleQuotedValue(s string, values map[string]string, lineNo int) (string, int, error) {
+	var b strings.Builder
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), i + 1, nil
+
+		case c == '\\' && i+1 < len(s):
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(s[i+1])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i+1])
+			}
+			i += 2
+
+		case c == '$':
+			name, consumed := readEnvFileVarRef(s[i+1:])
+			if consumed == 0 {
+				b.WriteByte(c)
+				i++
+				continue
+			}
+			b.WriteString(values[name])
+			i += 1 + consumed
+
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0, fmt.Errorf("%w: line %d: unterminated double-quoted value", ErrEnvFileMalformed, lineNo)
+}
+
+// readEnvFileVarRef parses a "${NAME}" or "NAME" variable reference from the
+// start of s (s is the text immediately following the '$'). It returns the
+// variable name and how many bytes of s were consumed, or ("", 0) if s does
+// not start with a valid reference.
+func readEnvFileVarRef(s string) (name string, consumed int) {
+	if len(s) > 0 && s[0] == '{' {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return "", 0
+		}
+		return s[1:end], end + 1
+	}
+
+	j := 0
+	for j < len(s) && (isEnvFileIdentByte(s[j])) {
+		j++
+	}
+	if j == 0 {
+		return "", 0
+	}
+	return s[:j], j
+}
+
+func isEnvFileIdentByte(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+func parseUnquotedValue(raw string) string {
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c == '#' && (i == 0 || raw[i-1] == ' ' || raw[i-1] == '\t') {
+			break
+		}
+		b.WriteByte(c)
+	}
+	return strings.TrimRight(b.String(), " \t")
+}
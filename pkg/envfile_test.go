@@ -0,0 +1,128 @@
+package processingEngine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "blank lines and comments are skipped",
+			input: "\n# a comment\n  \nKEY=value\n",
+			want:  []string{"KEY=value"},
+		},
+		{
+			name:  "leading export is accepted",
+			input: "export KEY=value\n",
+			want:  []string{"KEY=value"},
+		},
+		{
+			name:  "unquoted value runs to end of line",
+			input: "KEY=hello world\n",
+			want:  []string{"KEY=hello world"},
+		},
+		{
+			name:  "inline comment after unquoted value is stripped",
+			input: "KEY=value # trailing comment\n",
+			want:  []string{"KEY=value"},
+		},
+		{
+			name:  "hash inside unquoted value without preceding space is kept",
+			input: "KEY=val#ue\n",
+			want:  []string{"KEY=val#ue"},
+		},
+		{
+			name:  "single-quoted value is literal, no expansion",
+			input: "KEY='$OTHER literal'\n",
+			want:  []string{"KEY=$OTHER literal"},
+		},
+		{
+			name:  "single-quoted value may contain #",
+			input: "KEY='va#lue' # comment\n",
+			want:  []string{"KEY=va#lue"},
+		},
+		{
+			name:  "double-quoted value supports escapes",
+			input: `KEY="line1\nline2\ttabbed\"quoted\"\\backslash"` + "\n",
+			want:  []string{"KEY=line1\nline2\ttabbed\"quoted\"\\backslash"},
+		},
+		{
+			name:  "double-quoted value expands ${VAR} against earlier keys",
+			input: "FIRST=hello\nSECOND=\"${FIRST} world\"\n",
+			want:  []string{"FIRST=hello", "SECOND=hello world"},
+		},
+		{
+			name:  "double-quoted value expands bare $VAR against earlier keys",
+			input: "FIRST=hello\nSECOND=\"$FIRST world\"\n",
+			want:  []string{"FIRST=hello", "SECOND=hello world"},
+		},
+		{
+			name:  "undefined variable expands to empty string",
+			input: "SECOND=\"${MISSING}value\"\n",
+			want:  []string{"SECOND=value"},
+		},
+		{
+			name:  "line continuation joins the next line",
+			input: "KEY=first\\\nsecond\n",
+			want:  []string{"KEY=firstsecond"},
+		},
+		{
+			name:    "missing equals sign is malformed",
+			input:   "NOTKEYVALUE\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid key is malformed",
+			input:   "1INVALID=value\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated single-quoted value is malformed",
+			input:   "KEY='unterminated\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated double-quoted value is malformed",
+			input:   "KEY=\"unterminated\n",
+			wantErr: true,
+		},
+		{
+			name:    "garbage after closing quote is malformed",
+			input:   "KEY='value'garbage\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEnvFile(strings.NewReader(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseEnvFile() error = nil, want a malformed-line error")
+				}
+				if !errors.Is(err, ErrEnvFileMalformed) {
+					t.Errorf("ParseEnvFile() error = %v, want errors.Is(err, ErrEnvFileMalformed)", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseEnvFile() unexpected error: %s", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseEnvFile() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ParseEnvFile()[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}